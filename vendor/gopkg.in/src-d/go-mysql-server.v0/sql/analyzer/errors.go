@@ -0,0 +1,97 @@
+package analyzer
+
+import (
+	errors "gopkg.in/src-d/go-errors.v1"
+	"gopkg.in/src-d/go-mysql-server.v0/sql"
+)
+
+// ErrorCode is implemented by errors that know how to map themselves to a
+// MySQL error code and SQLSTATE, so the wire server can report them to
+// clients the way MySQL itself would instead of as a generic error 1105.
+type ErrorCode interface {
+	// MySQLCode returns the MySQL error number, e.g. 1054 for "unknown
+	// column".
+	MySQLCode() uint16
+	// SQLState returns the five-character SQLSTATE code, e.g. "42S22".
+	SQLState() string
+}
+
+// codedKind is an errors.Kind that additionally knows its MySQL error code
+// and SQLSTATE, so every error it creates implements ErrorCode.
+type codedKind struct {
+	*errors.Kind
+	mysqlCode uint16
+	sqlState  string
+}
+
+// codedError pairs a go-errors error with the MySQL code and SQLSTATE it is
+// reported with, taken from the codedKind that created it or, in
+// MySQLErrorCode, from externalKindCodes, so it can answer
+// MySQLCode/SQLState.
+type codedError struct {
+	error
+	mysqlCode uint16
+	sqlState  string
+}
+
+// MySQLCode implements ErrorCode.
+func (e *codedError) MySQLCode() uint16 { return e.mysqlCode }
+
+// SQLState implements ErrorCode.
+func (e *codedError) SQLState() string { return e.sqlState }
+
+// Cause unwraps to the underlying error, e.g. the *errors.Error the
+// codedKind it was built from actually created. Without this,
+// errors.Kind.Is walks off the end of the chain at a *codedError and never
+// reaches the *errors.Error it wraps, so Is would wrongly report that an
+// error isn't of the very kind that created it.
+func (e *codedError) Cause() error { return e.error }
+
+// Unwrap is the standard library's equivalent of Cause, kept in step with
+// it for code that walks chains with errors.Unwrap instead.
+func (e *codedError) Unwrap() error { return e.error }
+
+// New creates a new error from the kind, wrapped so it carries the kind's
+// MySQL code and SQLSTATE.
+func (k *codedKind) New(args ...interface{}) error {
+	return &codedError{k.Kind.New(args...), k.mysqlCode, k.sqlState}
+}
+
+// newCodedKind creates an errors.Kind with the given message format that
+// also carries a MySQL error code and SQLSTATE.
+func newCodedKind(format string, mysqlCode uint16, sqlState string) *codedKind {
+	return &codedKind{errors.NewKind(format), mysqlCode, sqlState}
+}
+
+// externalKindCodes maps errors.Kind values this package does not own (they
+// are declared in the sql package) to the MySQL code and SQLSTATE they
+// should be reported with.
+var externalKindCodes = map[*errors.Kind]struct {
+	mysqlCode uint16
+	sqlState  string
+}{
+	sql.ErrTableNotFound: {1146, "42S02"},
+}
+
+// MySQLErrorCode returns the MySQL error code and SQLSTATE for err, either
+// because it was created from a codedKind or because it wraps an
+// errors.Kind listed in externalKindCodes, and ok=false otherwise.
+//
+// This is the integration point the MySQL wire handler is expected to call
+// when translating a query error into a response packet, in place of
+// hardcoding the generic error 1105. That handler lives in the server
+// package, which is not part of this vendored snapshot, so the call site
+// itself is not wired up here; this package only provides the mapping.
+func MySQLErrorCode(err error) (code ErrorCode, ok bool) {
+	if ec, ok := err.(ErrorCode); ok {
+		return ec, true
+	}
+
+	if e, ok := err.(*errors.Error); ok {
+		if c, ok := externalKindCodes[e.Kind]; ok {
+			return &codedError{err, c.mysqlCode, c.sqlState}, true
+		}
+	}
+
+	return nil, false
+}
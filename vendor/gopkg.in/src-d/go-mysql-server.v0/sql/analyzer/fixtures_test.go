@@ -0,0 +1,57 @@
+package analyzer
+
+import (
+	"gopkg.in/src-d/go-mysql-server.v0/sql"
+)
+
+// testTable is a minimal sql.Table used across this package's tests to
+// build plans by hand, without depending on a real gitbase table or a
+// running engine.
+type testTable struct {
+	name   string
+	schema sql.Schema
+}
+
+// newTestTable builds a table named name whose schema is schema with every
+// column's Source set to name, so callers don't have to repeat it.
+func newTestTable(name string, schema sql.Schema) *testTable {
+	sourced := make(sql.Schema, len(schema))
+	for i, col := range schema {
+		c := *col
+		c.Source = name
+		sourced[i] = &c
+	}
+	return &testTable{name: name, schema: sourced}
+}
+
+func (t *testTable) Name() string         { return t.name }
+func (t *testTable) String() string       { return t.name }
+func (t *testTable) Schema() sql.Schema   { return t.schema }
+func (t *testTable) Resolved() bool       { return true }
+func (t *testTable) Children() []sql.Node { return nil }
+
+func (t *testTable) TransformUp(f func(sql.Node) (sql.Node, error)) (sql.Node, error) {
+	return f(t)
+}
+
+func (t *testTable) TransformExpressionsUp(f func(sql.Expression) (sql.Expression, error)) (sql.Node, error) {
+	return t, nil
+}
+
+func (t *testTable) RowIter(ctx *sql.Context) (sql.RowIter, error) {
+	return nil, nil
+}
+
+// primaryKeyTestTable is a testTable that additionally declares a primary
+// key, so tests can exercise the sql.PrimaryKeyTable branch of
+// computeFuncDeps.
+type primaryKeyTestTable struct {
+	*testTable
+	primaryKey []string
+}
+
+func newPrimaryKeyTestTable(name string, schema sql.Schema, primaryKey []string) *primaryKeyTestTable {
+	return &primaryKeyTestTable{testTable: newTestTable(name, schema), primaryKey: primaryKey}
+}
+
+func (t *primaryKeyTestTable) PrimaryKey() []string { return t.primaryKey }
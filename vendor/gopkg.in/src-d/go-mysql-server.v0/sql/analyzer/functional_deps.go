@@ -0,0 +1,334 @@
+package analyzer
+
+import (
+	"gopkg.in/src-d/go-mysql-server.v0/sql"
+	"gopkg.in/src-d/go-mysql-server.v0/sql/expression"
+	"gopkg.in/src-d/go-mysql-server.v0/sql/plan"
+)
+
+// ErrGroupByNotFunctionallyDependent is returned when a GROUP BY select-list
+// column is neither grouped on, aggregated, nor functionally dependent on
+// the group-by columns. Maps to MySQL error 1055 (ER_WRONG_FIELD_WITH_GROUP),
+// SQLSTATE 42000, matching only_full_group_by behavior.
+var ErrGroupByNotFunctionallyDependent = newCodedKind(
+	"column %q must appear in the GROUP BY clause or be used in an aggregate function",
+	1055,
+	"42000",
+)
+
+// validateGroupBy checks that every select-list column of a GroupBy that is
+// not itself an aggregation is functionally determined by the grouping
+// columns, using the FuncDeps of the GroupBy's child.
+func validateGroupBy(a *Analyzer, n sql.Node) (sql.Node, error) {
+	a.Log("validate group by, node of type: %T", n)
+	group, ok := n.(*plan.GroupBy)
+	if !ok || !group.Resolved() {
+		return n, nil
+	}
+
+	var groupCols []tableCol
+	for _, e := range group.Grouping {
+		groupCols = append(groupCols, exprTableCol(e))
+	}
+
+	fd := computeFuncDeps(group.Child)
+
+	for _, e := range group.Aggregations {
+		if containsAggregation(e) {
+			continue
+		}
+
+		col := exprTableCol(e)
+		if containsTableCol(groupCols, col) {
+			continue
+		}
+
+		if !fd.Determines(groupCols, col) {
+			return nil, ErrGroupByNotFunctionallyDependent.New(expression.GetName(e))
+		}
+	}
+
+	return n, nil
+}
+
+// exprTableCol identifies the (table, column) pair an expression reads from,
+// so functional dependencies can be tracked without conflating two different
+// tables' same-named column (e.g. both sides of a join declaring "hash").
+// An expression that isn't a plain column reference - a computed expression,
+// or an Alias over one - has no source table of its own, so it's identified
+// by its output name alone, the same name a parent would see in GetName.
+func exprTableCol(e sql.Expression) tableCol {
+	switch e := e.(type) {
+	case *expression.GetField:
+		return tableCol{e.Table(), e.Name()}
+	default:
+		return tableCol{"", expression.GetName(e)}
+	}
+}
+
+func containsTableCol(in []tableCol, c tableCol) bool {
+	for _, v := range in {
+		if v == c {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAggregation reports whether e is, or wraps (e.g. via an Alias),
+// an aggregation. A select-list item such as `SUM(x) AS total` is an Alias
+// at the top level, so a bare type assertion against sql.Aggregation would
+// miss it and wrongly demand that `total` be functionally dependent on the
+// GROUP BY columns.
+func containsAggregation(e sql.Expression) bool {
+	found := false
+	expression.Inspect(e, func(e sql.Expression) bool {
+		if _, ok := e.(sql.Aggregation); ok {
+			found = true
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// FuncDeps describes what is known about the rows a plan node produces: the
+// columns that together form a strict key, columns that are known to be
+// equivalent to each other, columns fixed to a single value by a filter, and
+// the ordered prefix of columns guaranteed by a Sort below the node. Every
+// column is identified by its (table, name) pair rather than by bare name:
+// two tables on either side of a join can declare a same-named column (e.g.
+// both refs and commits have a "hash"), and a key or equivalence proven for
+// one must never be attributed to the other.
+//
+// It is rebuilt bottom-up every time optimizeDistinct runs, rather than
+// cached on the node, since the analyzer already re-runs its rules to a
+// fixed point and plans are immutable.
+type FuncDeps struct {
+	// Keys are sets of columns that, taken together, uniquely identify a
+	// row. A table's declared primary key is one such set.
+	Keys [][]tableCol
+	// Equivalences groups columns known to hold equal values, e.g. because
+	// of an `a = b` filter or an equi-join predicate.
+	Equivalences [][]tableCol
+	// Constants are columns fixed to a single value by a filter, e.g. `x`
+	// in `WHERE x = 5`.
+	Constants []tableCol
+	// SortPrefix is the ordered list of columns a Sort below this node
+	// guarantees the rows come in.
+	SortPrefix []tableCol
+}
+
+// Determines reports whether target is functionally determined by the given
+// set of columns, i.e. whether knowing the values of cols is enough to know
+// the value of target. This accounts for declared keys (any column is
+// determined by a full key), equivalences (a column equivalent to one of
+// cols counts as determined) and constants (a column fixed to a constant by
+// a filter is determined by any set of columns, even an empty one).
+func (fd FuncDeps) Determines(cols []tableCol, target tableCol) bool {
+	set := make(map[tableCol]struct{}, len(cols))
+	for _, c := range cols {
+		set[c] = struct{}{}
+	}
+
+	if _, ok := set[target]; ok {
+		return true
+	}
+
+	for _, class := range fd.Equivalences {
+		if !containsTableCol(class, target) {
+			continue
+		}
+		for _, c := range class {
+			if _, ok := set[c]; ok {
+				return true
+			}
+		}
+	}
+
+	if containsTableCol(fd.Constants, target) {
+		return true
+	}
+
+	for _, key := range fd.Keys {
+		allPresent := true
+		for _, k := range key {
+			if _, ok := set[k]; !ok {
+				allPresent = false
+				break
+			}
+		}
+		if allPresent {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasKeySubsetOf reports whether some known strict key is a subset of cols,
+// i.e. whether cols contains a whole key, meaning a Distinct over exactly
+// those columns would never remove a row.
+func (fd FuncDeps) HasKeySubsetOf(cols []tableCol) bool {
+	colSet := make(map[tableCol]struct{}, len(cols))
+	for _, c := range cols {
+		colSet[c] = struct{}{}
+	}
+
+	for _, key := range fd.Keys {
+		isSubset := true
+		for _, k := range key {
+			if _, ok := colSet[k]; !ok {
+				isSubset = false
+				break
+			}
+		}
+		if isSubset {
+			return true
+		}
+	}
+
+	return false
+}
+
+// computeFuncDeps computes the FuncDeps of n from the bottom up.
+func computeFuncDeps(n sql.Node) FuncDeps {
+	switch n := n.(type) {
+	case sql.Table:
+		var fd FuncDeps
+		if pk, ok := n.(sql.PrimaryKeyTable); ok {
+			var key []tableCol
+			for _, name := range pk.PrimaryKey() {
+				key = append(key, tableCol{n.Name(), name})
+			}
+			fd.Keys = [][]tableCol{key}
+		}
+		return fd
+	case *plan.Filter:
+		fd := computeFuncDeps(n.Child)
+		for _, e := range splitExpression(n.Expression) {
+			addConjunctToFuncDeps(&fd, e)
+		}
+		return fd
+	case *plan.Project:
+		child := computeFuncDeps(n.Child)
+		return projectFuncDeps(child, n.Expressions)
+	case *plan.Sort:
+		fd := computeFuncDeps(n.Child)
+		var prefix []tableCol
+		for _, f := range n.SortFields {
+			prefix = append(prefix, exprTableCol(f.Column))
+		}
+		fd.SortPrefix = prefix
+		return fd
+	case *plan.InnerJoin:
+		return joinFuncDeps(computeFuncDeps(n.Left), computeFuncDeps(n.Right), n.Cond)
+	default:
+		var fd FuncDeps
+		for _, child := range n.Children() {
+			childFd := computeFuncDeps(child)
+			fd.Keys = append(fd.Keys, childFd.Keys...)
+			fd.Equivalences = append(fd.Equivalences, childFd.Equivalences...)
+			fd.Constants = append(fd.Constants, childFd.Constants...)
+		}
+		return fd
+	}
+}
+
+// addConjunctToFuncDeps folds a single Filter conjunct into fd: `a = b`
+// becomes an equivalence, `a = <literal>` becomes a constant.
+func addConjunctToFuncDeps(fd *FuncDeps, e sql.Expression) {
+	eq, ok := e.(*expression.Equals)
+	if !ok {
+		return
+	}
+
+	left, leftOk := eq.Left().(*expression.GetField)
+	right, rightOk := eq.Right().(*expression.GetField)
+
+	switch {
+	case leftOk && rightOk:
+		fd.Equivalences = append(fd.Equivalences, []tableCol{
+			{left.Table(), left.Name()},
+			{right.Table(), right.Name()},
+		})
+	case leftOk && !rightOk:
+		fd.Constants = append(fd.Constants, tableCol{left.Table(), left.Name()})
+	case rightOk && !leftOk:
+		fd.Constants = append(fd.Constants, tableCol{right.Table(), right.Name()})
+	}
+}
+
+// projectFuncDeps maps a child's FuncDeps through a projection list, keeping
+// only the information that still applies to the projected output columns.
+func projectFuncDeps(child FuncDeps, exprs []sql.Expression) FuncDeps {
+	var out []tableCol
+	for _, e := range exprs {
+		out = append(out, exprTableCol(e))
+	}
+	outSet := make(map[tableCol]struct{}, len(out))
+	for _, o := range out {
+		outSet[o] = struct{}{}
+	}
+
+	filterCols := func(cols []tableCol) []tableCol {
+		var result []tableCol
+		for _, c := range cols {
+			if _, ok := outSet[c]; ok {
+				result = append(result, c)
+			}
+		}
+		return result
+	}
+
+	var fd FuncDeps
+	for _, key := range child.Keys {
+		if k := filterCols(key); len(k) == len(key) {
+			fd.Keys = append(fd.Keys, k)
+		}
+	}
+	for _, class := range child.Equivalences {
+		if c := filterCols(class); len(c) > 1 {
+			fd.Equivalences = append(fd.Equivalences, c)
+		}
+	}
+	fd.Constants = filterCols(child.Constants)
+	fd.SortPrefix = filterCols(child.SortPrefix)
+	return fd
+}
+
+// joinFuncDeps combines the FuncDeps of both sides of a join and adds an
+// equivalence for each equi-join key found in cond. left and right's columns
+// are already qualified by their own source table, so a same-named column
+// declared on both sides (e.g. both tables having a "hash") stays two
+// distinct entries instead of merging into one.
+func joinFuncDeps(left, right FuncDeps, cond sql.Expression) FuncDeps {
+	var fd FuncDeps
+	fd.Keys = append(fd.Keys, left.Keys...)
+	fd.Keys = append(fd.Keys, right.Keys...)
+	fd.Equivalences = append(fd.Equivalences, left.Equivalences...)
+	fd.Equivalences = append(fd.Equivalences, right.Equivalences...)
+	fd.Constants = append(fd.Constants, left.Constants...)
+	fd.Constants = append(fd.Constants, right.Constants...)
+
+	for _, e := range splitExpression(cond) {
+		addConjunctToFuncDeps(&fd, e)
+	}
+
+	return fd
+}
+
+// isSupersetOf reports whether every column in need is present in have,
+// regardless of order.
+func isSupersetOf(have, need []tableCol) bool {
+	set := make(map[tableCol]struct{}, len(have))
+	for _, h := range have {
+		set[h] = struct{}{}
+	}
+	for _, n := range need {
+		if _, ok := set[n]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,178 @@
+package analyzer
+
+import (
+	"testing"
+
+	"gopkg.in/src-d/go-mysql-server.v0/sql"
+	"gopkg.in/src-d/go-mysql-server.v0/sql/expression"
+	"gopkg.in/src-d/go-mysql-server.v0/sql/plan"
+)
+
+func TestContainsAggregationBareAggregation(t *testing.T) {
+	sum := expression.NewSum(expression.NewGetFieldWithTable(0, sql.Int32, "t", "x", false))
+
+	if !containsAggregation(sum) {
+		t.Fatalf("expected a bare aggregation to be recognized")
+	}
+}
+
+// TestContainsAggregationAliasedAggregation is the scenario from
+// requests/chunk0-5: `SUM(x) AS total` is an *expression.Alias wrapping the
+// aggregation, and must still be recognized so a standard GROUP BY query is
+// not wrongly rejected.
+func TestContainsAggregationAliasedAggregation(t *testing.T) {
+	sum := expression.NewSum(expression.NewGetFieldWithTable(0, sql.Int32, "t", "x", false))
+	aliased := expression.NewAlias(sum, "total")
+
+	if !containsAggregation(aliased) {
+		t.Fatalf("expected an aggregation wrapped in an Alias to be recognized")
+	}
+}
+
+func TestContainsAggregationNoAggregation(t *testing.T) {
+	field := expression.NewGetFieldWithTable(0, sql.Int32, "t", "g", false)
+
+	if containsAggregation(field) {
+		t.Fatalf("did not expect a plain column reference to be reported as an aggregation")
+	}
+}
+
+func TestValidateGroupByAcceptsAliasedAggregation(t *testing.T) {
+	table := newTestTable("t", sql.Schema{
+		{Name: "g", Type: sql.Int32},
+		{Name: "x", Type: sql.Int32},
+	})
+
+	grouping := []sql.Expression{expression.NewGetFieldWithTable(0, sql.Int32, "t", "g", false)}
+	aggregations := []sql.Expression{
+		expression.NewGetFieldWithTable(0, sql.Int32, "t", "g", false),
+		expression.NewAlias(
+			expression.NewSum(expression.NewGetFieldWithTable(1, sql.Int32, "t", "x", false)),
+			"total",
+		),
+	}
+
+	group := plan.NewGroupBy(aggregations, grouping, table)
+
+	if _, err := validateGroupBy(new(Analyzer), group); err != nil {
+		t.Fatalf("validateGroupBy rejected a standard aliased-aggregation GROUP BY: %v", err)
+	}
+}
+
+func TestValidateGroupByRejectsNonDependentColumn(t *testing.T) {
+	table := newTestTable("t", sql.Schema{
+		{Name: "g", Type: sql.Int32},
+		{Name: "x", Type: sql.Int32},
+	})
+
+	grouping := []sql.Expression{expression.NewGetFieldWithTable(0, sql.Int32, "t", "g", false)}
+	aggregations := []sql.Expression{
+		expression.NewGetFieldWithTable(0, sql.Int32, "t", "g", false),
+		expression.NewGetFieldWithTable(1, sql.Int32, "t", "x", false),
+	}
+
+	group := plan.NewGroupBy(aggregations, grouping, table)
+
+	if _, err := validateGroupBy(new(Analyzer), group); err == nil {
+		t.Fatalf("expected an error for a select-list column that is neither grouped, aggregated, nor functionally dependent")
+	}
+}
+
+func TestFuncDepsDeterminesViaKey(t *testing.T) {
+	fd := FuncDeps{Keys: [][]tableCol{{{"t", "id"}}}}
+
+	if !fd.Determines([]tableCol{{"t", "id"}}, tableCol{"t", "name"}) {
+		t.Fatalf("expected a column to be determined by a full key")
+	}
+	if fd.Determines([]tableCol{{"t", "other"}}, tableCol{"t", "name"}) {
+		t.Fatalf("did not expect a column to be determined by an unrelated column")
+	}
+}
+
+func TestFuncDepsDeterminesViaEquivalence(t *testing.T) {
+	fd := FuncDeps{Equivalences: [][]tableCol{{{"t", "a"}, {"t", "b"}}}}
+
+	if !fd.Determines([]tableCol{{"t", "a"}}, tableCol{"t", "b"}) {
+		t.Fatalf("expected b to be determined by a, since they're known equivalent")
+	}
+}
+
+func TestFuncDepsHasKeySubsetOf(t *testing.T) {
+	fd := FuncDeps{Keys: [][]tableCol{{{"t", "id"}}}}
+
+	if !fd.HasKeySubsetOf([]tableCol{{"t", "id"}, {"t", "name"}}) {
+		t.Fatalf("expected {id, name} to contain the key {id}")
+	}
+	if fd.HasKeySubsetOf([]tableCol{{"t", "name"}}) {
+		t.Fatalf("did not expect {name} to contain the key {id}")
+	}
+}
+
+// TestFuncDepsDeterminesDoesNotConflateSameNamedColumnsAcrossTables is the
+// scenario from requests/chunk0-5's review: two tables that both declare a
+// column named "hash" must not have a key/equivalence proven for one
+// table's "hash" satisfy a query about the other table's "hash".
+func TestFuncDepsDeterminesDoesNotConflateSameNamedColumnsAcrossTables(t *testing.T) {
+	fd := FuncDeps{Keys: [][]tableCol{{{"refs", "hash"}}}}
+
+	if fd.Determines([]tableCol{{"commits", "hash"}}, tableCol{"commits", "author_email"}) {
+		t.Fatalf("a key proven for refs.hash must not determine a column via commits.hash")
+	}
+}
+
+// TestComputeFuncDepsJoinKeepsSameNamedColumnsDistinctPerSide builds
+// computeFuncDeps over an InnerJoin of two tables that both declare "hash"
+// as their primary key and asserts the two keys remain distinguishable by
+// source table instead of merging into one ambiguous "hash" key.
+func TestComputeFuncDepsJoinKeepsSameNamedColumnsDistinctPerSide(t *testing.T) {
+	refs := newPrimaryKeyTestTable("refs", sql.Schema{
+		{Name: "hash", Type: sql.Text},
+		{Name: "name", Type: sql.Text},
+	}, []string{"hash"})
+	commits := newPrimaryKeyTestTable("commits", sql.Schema{
+		{Name: "hash", Type: sql.Text},
+		{Name: "author_email", Type: sql.Text},
+	}, []string{"hash"})
+
+	join := plan.NewInnerJoin(refs, commits, expression.NewEquals(
+		expression.NewGetFieldWithTable(0, sql.Text, "refs", "hash", false),
+		expression.NewGetFieldWithTable(2, sql.Text, "commits", "hash", false),
+	))
+
+	fd := computeFuncDeps(join)
+
+	if !fd.HasKeySubsetOf([]tableCol{{"refs", "hash"}}) {
+		t.Fatalf("expected refs.hash to still be recognized as refs' own key")
+	}
+	if !fd.HasKeySubsetOf([]tableCol{{"commits", "hash"}}) {
+		t.Fatalf("expected commits.hash to still be recognized as commits' own key")
+	}
+	// A single ref to "hash" with no table qualifier must never satisfy
+	// either key: that would mean the two distinct keys had been conflated
+	// into one by bare column name.
+	if fd.HasKeySubsetOf([]tableCol{{"", "hash"}}) {
+		t.Fatalf("an unqualified hash must not be treated as satisfying either table's key")
+	}
+}
+
+// TestOptimizeDistinctRemovesDistinctOverUniqueChild is the scenario
+// requests/chunk0-5 originally asked for: a Distinct over a child whose
+// output is already a superset of a declared primary key must be elided
+// entirely.
+func TestOptimizeDistinctRemovesDistinctOverUniqueChild(t *testing.T) {
+	table := newPrimaryKeyTestTable("t", sql.Schema{
+		{Name: "id", Type: sql.Int32},
+		{Name: "name", Type: sql.Text},
+	}, []string{"id"})
+
+	distinct := &plan.Distinct{Child: table}
+
+	result, err := optimizeDistinct(new(Analyzer), distinct)
+	if err != nil {
+		t.Fatalf("optimizeDistinct: %v", err)
+	}
+
+	if result != table {
+		t.Fatalf("expected the Distinct to be elided entirely, got %T", result)
+	}
+}
@@ -0,0 +1,282 @@
+package analyzer
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"gopkg.in/src-d/go-mysql-server.v0/sql"
+	"gopkg.in/src-d/go-mysql-server.v0/sql/expression"
+	"gopkg.in/src-d/go-mysql-server.v0/sql/plan"
+)
+
+// inSubqueryAliasSeq is used to mint a unique alias for every unnested
+// subquery. pushdown keys fieldsByTable/exprsByTable/filters purely by table
+// name across the whole plan, so two unrelated IN-subqueries reusing the
+// same alias would have their columns and filters cross-applied to each
+// other's subplan.
+var inSubqueryAliasSeq uint64
+
+func nextInSubqueryAlias() string {
+	return fmt.Sprintf("__unnested_in_subquery_%d", atomic.AddUint64(&inSubqueryAliasSeq, 1))
+}
+
+// unnestInSubqueries rewrites `expr IN (SELECT ...)` and
+// `expr NOT IN (SELECT ...)` predicates found in a Filter into a SemiJoin or
+// AntiSemiJoin, so the engine can run them as a join instead of re-executing
+// the subquery per outer row.
+func unnestInSubqueries(a *Analyzer, n sql.Node) (sql.Node, error) {
+	a.Log("unnest in subqueries, node of type: %T", n)
+	return n.TransformUp(func(n sql.Node) (sql.Node, error) {
+		filter, ok := n.(*plan.Filter)
+		if !ok {
+			return n, nil
+		}
+
+		outer := filter.Child
+		var (
+			rewritten sql.Node
+			handled   bool
+		)
+
+		newExpr, terr := filter.Expression.TransformUp(func(e sql.Expression) (sql.Expression, error) {
+			if handled {
+				return e, nil
+			}
+
+			negate := false
+			in, ok := e.(*expression.InSubquery)
+			if !ok {
+				notIn, ok := e.(*expression.NotInSubquery)
+				if !ok {
+					return e, nil
+				}
+				negate = true
+				in = notIn.InSubquery
+			}
+
+			sq, ok := in.Right().(*expression.Subquery)
+			if !ok {
+				return e, nil
+			}
+
+			inner := sq.Query
+			if !inner.Resolved() {
+				return e, nil
+			}
+
+			innerSchema := inner.Schema()
+			if len(innerSchema) != 1 {
+				return e, nil
+			}
+
+			aliased := plan.NewSubqueryAlias(nextInSubqueryAlias(), inner)
+
+			joinCond, innerPlan, cerr := buildSemiJoinCondition(outer, in.Left(), aliased, inner)
+			if cerr != nil {
+				return nil, cerr
+			}
+
+			if negate {
+				rewritten = plan.NewAntiSemiJoin(outer, innerPlan, joinCond)
+			} else {
+				rewritten = plan.NewSemiJoin(outer, innerPlan, joinCond)
+			}
+
+			handled = true
+			return nil, nil
+		})
+		if terr != nil {
+			return nil, terr
+		}
+
+		if !handled {
+			return n, nil
+		}
+
+		// The unnested predicate was replaced by nil above, so it must not be
+		// kept as a remaining conjunct of the outer Filter.
+		var remaining []sql.Expression
+		for _, e := range splitExpression(newExpr) {
+			if e != nil {
+				remaining = append(remaining, e)
+			}
+		}
+		if len(remaining) == 0 {
+			return rewritten, nil
+		}
+
+		fixed, ferr := fixFieldIndexesOnExpressions(rewritten.Schema(), remaining...)
+		if ferr != nil {
+			return nil, ferr
+		}
+
+		return plan.NewFilter(expression.JoinAnd(fixed...), rewritten), nil
+	})
+}
+
+// buildSemiJoinCondition builds the join condition for the semi join that
+// replaces an IN-subquery. The condition is evaluated against the row the
+// SemiJoin itself produces, which is outer's row concatenated with inner's
+// row (in that order) - the same row-layout convention resolveNaturalJoins
+// relies on for its right side's `len(leftSchema)+ri` offset - so every
+// GetField addressing the inner side must be offset by len(outer.Schema()).
+// For an uncorrelated subquery this is simply `left = innerCol`. For a
+// correlated one, the correlation predicate is pulled out of the subquery's
+// own Filter so it becomes part of the join condition, leaving the rest of
+// the subquery's predicates inside it.
+func buildSemiJoinCondition(outer sql.Node, left sql.Expression, aliased *plan.SubqueryAlias, inner sql.Node) (sql.Expression, sql.Node, error) {
+	offset := len(outer.Schema())
+
+	innerCol := expression.NewGetFieldWithTable(
+		offset,
+		inner.Schema()[0].Type,
+		aliased.Name(),
+		inner.Schema()[0].Name,
+		inner.Schema()[0].Nullable,
+	)
+
+	if !isCorrelated(inner) {
+		return expression.NewEquals(left, innerCol), aliased, nil
+	}
+
+	newChild, corr, err := pullOutCorrelation(aliased.Child)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	innerTables := make(map[string]struct{})
+	collectTableNames(inner, innerTables)
+
+	offsetCorr, err := offsetInnerGetFields(corr, innerTables, offset)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cond := expression.JoinAnd(append([]sql.Expression{expression.NewEquals(left, innerCol)}, offsetCorr...)...)
+	return cond, plan.NewSubqueryAlias(aliased.Name(), newChild), nil
+}
+
+// offsetInnerGetFields rewrites every GetField in exprs that addresses one
+// of innerTables by adding offset to its index. pullOutCorrelation returns
+// correlation predicates with their GetFields still indexed against the
+// standalone subquery's own row, but once hoisted into the SemiJoin's join
+// condition the inner side's columns sit offset columns further along the
+// concatenated outer+inner row - left unadjusted, the predicate would read
+// whatever column happens to land at that position in the outer row instead.
+func offsetInnerGetFields(exprs []sql.Expression, innerTables map[string]struct{}, offset int) ([]sql.Expression, error) {
+	result := make([]sql.Expression, len(exprs))
+	for i, e := range exprs {
+		fixed, err := e.TransformUp(func(e sql.Expression) (sql.Expression, error) {
+			gf, ok := e.(*expression.GetField)
+			if !ok {
+				return e, nil
+			}
+			if _, ok := innerTables[gf.Table()]; !ok {
+				return e, nil
+			}
+			return expression.NewGetFieldWithTable(
+				gf.Index()+offset,
+				gf.Type(),
+				gf.Table(),
+				gf.Name(),
+				gf.IsNullable(),
+			), nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		result[i] = fixed
+	}
+	return result, nil
+}
+
+// isCorrelated reports whether the plan references a GetField whose table is
+// not produced by any node inside the plan itself, i.e. it refers to a
+// column from the outer query.
+func isCorrelated(n sql.Node) bool {
+	known := make(map[string]struct{})
+	collectTableNames(n, known)
+
+	var correlated bool
+	inspectNodeExpressions(n, func(e sql.Expression) bool {
+		if gf, ok := e.(*expression.GetField); ok {
+			if _, ok := known[gf.Table()]; !ok {
+				correlated = true
+			}
+		}
+		return true
+	})
+
+	return correlated
+}
+
+// inspectNodeExpressions walks every expression in every node of the plan
+// rooted at n. n is a sql.Node, not a sql.Expression, so expression.Inspect
+// can't be called on it directly: TransformExpressionsUp is the node method
+// that hands us each of a single node's own expressions, and we recurse into
+// Children() ourselves to cover the rest of the tree.
+func inspectNodeExpressions(n sql.Node, f func(sql.Expression) bool) {
+	_, _ = n.TransformExpressionsUp(func(e sql.Expression) (sql.Expression, error) {
+		expression.Inspect(e, f)
+		return e, nil
+	})
+
+	for _, child := range n.Children() {
+		inspectNodeExpressions(child, f)
+	}
+}
+
+func collectTableNames(n sql.Node, known map[string]struct{}) {
+	if t, ok := n.(sql.Table); ok {
+		known[t.Name()] = struct{}{}
+	}
+	for _, child := range n.Children() {
+		collectTableNames(child, known)
+	}
+}
+
+// pullOutCorrelation removes the conjuncts of the subquery's Filter that
+// reference an outer column, returning the rewritten subquery plan and the
+// extracted predicates to be used as part of the join condition.
+func pullOutCorrelation(n sql.Node) (sql.Node, []sql.Expression, error) {
+	known := make(map[string]struct{})
+	collectTableNames(n, known)
+
+	var correlation []sql.Expression
+	newNode, err := n.TransformUp(func(n sql.Node) (sql.Node, error) {
+		filter, ok := n.(*plan.Filter)
+		if !ok {
+			return n, nil
+		}
+
+		var remaining []sql.Expression
+		for _, e := range splitExpression(filter.Expression) {
+			isOuter := false
+			expression.Inspect(e, func(e sql.Expression) bool {
+				if gf, ok := e.(*expression.GetField); ok {
+					if _, ok := known[gf.Table()]; !ok {
+						isOuter = true
+					}
+				}
+				return true
+			})
+
+			if isOuter {
+				correlation = append(correlation, e)
+			} else {
+				remaining = append(remaining, e)
+			}
+		}
+
+		if len(remaining) == 0 {
+			return filter.Child, nil
+		}
+
+		return plan.NewFilter(expression.JoinAnd(remaining...), filter.Child), nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return newNode, correlation, nil
+}
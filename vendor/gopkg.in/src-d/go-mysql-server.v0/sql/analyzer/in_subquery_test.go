@@ -0,0 +1,156 @@
+package analyzer
+
+import (
+	"testing"
+
+	"gopkg.in/src-d/go-mysql-server.v0/sql"
+	"gopkg.in/src-d/go-mysql-server.v0/sql/expression"
+	"gopkg.in/src-d/go-mysql-server.v0/sql/plan"
+)
+
+func TestNextInSubqueryAliasIsUniquePerCall(t *testing.T) {
+	first := nextInSubqueryAlias()
+	second := nextInSubqueryAlias()
+
+	if first == second {
+		t.Fatalf("expected two distinct aliases, both got %q", first)
+	}
+}
+
+func TestIsCorrelatedUncorrelatedSubquery(t *testing.T) {
+	inner := newTestTable("t2", sql.Schema{{Name: "id", Type: sql.Int32}})
+	filter := plan.NewFilter(expression.NewEquals(
+		expression.NewGetFieldWithTable(0, sql.Int32, "t2", "id", false),
+		expression.NewLiteral(int32(1), sql.Int32),
+	), inner)
+
+	if isCorrelated(filter) {
+		t.Fatalf("expected an uncorrelated subquery (referencing only its own table) to not be reported as correlated")
+	}
+}
+
+func TestIsCorrelatedCorrelatedSubquery(t *testing.T) {
+	inner := newTestTable("t2", sql.Schema{{Name: "id", Type: sql.Int32}})
+	filter := plan.NewFilter(expression.NewEquals(
+		expression.NewGetFieldWithTable(0, sql.Int32, "t2", "id", false),
+		expression.NewGetFieldWithTable(0, sql.Int32, "t1", "id", false),
+	), inner)
+
+	if !isCorrelated(filter) {
+		t.Fatalf("expected a subquery referencing the outer table %q to be reported as correlated", "t1")
+	}
+}
+
+// TestUnnestInSubqueriesUsesDistinctAliasesPerCallSite is the scenario from
+// requests/chunk0-4: two independent IN-subqueries in the same plan must not
+// end up sharing an alias, since pushdown keys its column/filter maps purely
+// by table name across the whole tree.
+func TestUnnestInSubqueriesUsesDistinctAliasesPerCallSite(t *testing.T) {
+	leftOuter := newTestTable("t1", sql.Schema{{Name: "a", Type: sql.Int32}})
+	leftInner := newTestTable("t2", sql.Schema{{Name: "id", Type: sql.Int32}})
+	leftFilter := plan.NewFilter(expression.NewInSubquery(
+		expression.NewGetFieldWithTable(0, sql.Int32, "t1", "a", false),
+		expression.NewSubquery(leftInner),
+	), leftOuter)
+
+	rightOuter := newTestTable("t3", sql.Schema{{Name: "b", Type: sql.Int32}})
+	rightInner := newTestTable("t4", sql.Schema{{Name: "id", Type: sql.Int32}})
+	rightFilter := plan.NewFilter(expression.NewInSubquery(
+		expression.NewGetFieldWithTable(0, sql.Int32, "t3", "b", false),
+		expression.NewSubquery(rightInner),
+	), rightOuter)
+
+	top := plan.NewInnerJoin(leftFilter, rightFilter, expression.NewEquals(
+		expression.NewGetFieldWithTable(0, sql.Int32, "t1", "a", false),
+		expression.NewGetFieldWithTable(0, sql.Int32, "t3", "b", false),
+	))
+
+	result, err := unnestInSubqueries(new(Analyzer), top)
+	if err != nil {
+		t.Fatalf("unnestInSubqueries: %v", err)
+	}
+
+	// Both sides of the join contain an independent IN-subquery; each must be
+	// unnested under its own unique alias, or pushdown (which keys its
+	// column/filter maps purely by table name) would cross-apply one
+	// subquery's columns and filters to the other.
+	var aliasNames []string
+	collectSubqueryAliasNames(result, &aliasNames)
+
+	if len(aliasNames) != 2 {
+		t.Fatalf("expected both IN-subqueries to be unnested into their own SubqueryAlias, got %d", len(aliasNames))
+	}
+	seen := make(map[string]struct{})
+	for _, name := range aliasNames {
+		if _, ok := seen[name]; ok {
+			t.Fatalf("expected every unnested subquery alias to be unique, saw %q twice", name)
+		}
+		seen[name] = struct{}{}
+		if name == "__unnested_in_subquery" {
+			t.Fatalf("alias must not be the old hardcoded literal %q", name)
+		}
+	}
+}
+
+// TestUnnestInSubqueriesCorrelatedOffsetsInnerGetFields is the correlated
+// rewrite path from requests/chunk0-4: `t1.a IN (SELECT id FROM t2 WHERE
+// t2.id = t1.a AND t2.id = 5)`. The correlation predicate `t2.id = t1.a` is
+// pulled out of the subquery's own Filter and folded into the SemiJoin's
+// join condition, which is evaluated against outer's row concatenated with
+// inner's row - so the inner side's GetField must be reindexed to land
+// after outer's single column, exactly as resolveNaturalJoins offsets the
+// right side of a join by len(leftSchema).
+func TestUnnestInSubqueriesCorrelatedOffsetsInnerGetFields(t *testing.T) {
+	outer := newTestTable("t1", sql.Schema{{Name: "a", Type: sql.Int32}})
+	inner := newTestTable("t2", sql.Schema{{Name: "id", Type: sql.Int32}})
+
+	innerFilter := plan.NewFilter(expression.JoinAnd(
+		expression.NewEquals(
+			expression.NewGetFieldWithTable(0, sql.Int32, "t2", "id", false),
+			expression.NewGetFieldWithTable(0, sql.Int32, "t1", "a", false),
+		),
+		expression.NewEquals(
+			expression.NewGetFieldWithTable(0, sql.Int32, "t2", "id", false),
+			expression.NewLiteral(int32(5), sql.Int32),
+		),
+	), inner)
+
+	outerFilter := plan.NewFilter(expression.NewInSubquery(
+		expression.NewGetFieldWithTable(0, sql.Int32, "t1", "a", false),
+		expression.NewSubquery(innerFilter),
+	), outer)
+
+	result, err := unnestInSubqueries(new(Analyzer), outerFilter)
+	if err != nil {
+		t.Fatalf("unnestInSubqueries: %v", err)
+	}
+
+	join, ok := result.(*plan.SemiJoin)
+	if !ok {
+		t.Fatalf("expected a correlated IN-subquery to unnest into a *plan.SemiJoin, got %T", result)
+	}
+
+	var t2Field *expression.GetField
+	expression.Inspect(join.Cond, func(e sql.Expression) bool {
+		if gf, ok := e.(*expression.GetField); ok && gf.Table() == "t2" {
+			t2Field = gf
+		}
+		return true
+	})
+
+	if t2Field == nil {
+		t.Fatalf("expected the hoisted correlation predicate to still reference t2.id in the join condition")
+	}
+	if t2Field.Index() != 1 {
+		t.Fatalf("expected t2.id to be reindexed to 1 (offset by outer's 1-column schema), got %d", t2Field.Index())
+	}
+}
+
+func collectSubqueryAliasNames(n sql.Node, out *[]string) {
+	if alias, ok := n.(*plan.SubqueryAlias); ok {
+		*out = append(*out, alias.Name())
+	}
+	for _, child := range n.Children() {
+		collectSubqueryAliasNames(child, out)
+	}
+}
@@ -0,0 +1,105 @@
+package analyzer
+
+import (
+	"strings"
+
+	"gopkg.in/src-d/go-mysql-server.v0/sql"
+	"gopkg.in/src-d/go-mysql-server.v0/sql/expression"
+	"gopkg.in/src-d/go-mysql-server.v0/sql/plan"
+)
+
+// ErrNaturalJoinNoCommonColumns is returned when a NATURAL JOIN is attempted
+// between two tables that share no column names. Maps to MySQL error 1054
+// (ER_BAD_FIELD_ERROR), SQLSTATE 42S22, since MySQL itself rejects the
+// query while resolving the (absent) common columns.
+var ErrNaturalJoinNoCommonColumns = newCodedKind(
+	"can't use natural join: tables have no columns in common",
+	1054,
+	"42S22",
+)
+
+// resolveNaturalJoins rewrites every plan.NaturalJoin into an InnerJoin over
+// the columns the two sides have in common, followed by a Project that
+// coalesces those shared columns into one and passes the rest through
+// unchanged.
+func resolveNaturalJoins(a *Analyzer, n sql.Node) (sql.Node, error) {
+	a.Log("resolve natural joins, node of type: %T", n)
+	return n.TransformUp(func(n sql.Node) (sql.Node, error) {
+		join, ok := n.(*plan.NaturalJoin)
+		if !ok {
+			return n, nil
+		}
+
+		if !join.Left.Resolved() || !join.Right.Resolved() {
+			return n, nil
+		}
+
+		leftSchema := join.Left.Schema()
+		rightSchema := join.Right.Schema()
+
+		var (
+			cond      sql.Expression
+			projExprs []sql.Expression
+			shared    = make(map[string]struct{})
+		)
+
+		for li, lcol := range leftSchema {
+			for ri, rcol := range rightSchema {
+				if !strings.EqualFold(lcol.Name, rcol.Name) {
+					continue
+				}
+
+				shared[strings.ToLower(lcol.Name)] = struct{}{}
+
+				eq := expression.NewEquals(
+					expression.NewGetFieldWithTable(li, lcol.Type, lcol.Source, lcol.Name, lcol.Nullable),
+					expression.NewGetFieldWithTable(len(leftSchema)+ri, rcol.Type, rcol.Source, rcol.Name, rcol.Nullable),
+				)
+
+				if cond == nil {
+					cond = eq
+				} else {
+					cond = expression.JoinAnd(cond, eq)
+				}
+
+				projExprs = append(projExprs, expression.NewAlias(
+					expression.NewCoalesce(
+						expression.NewGetFieldWithTable(li, lcol.Type, lcol.Source, lcol.Name, lcol.Nullable),
+						expression.NewGetFieldWithTable(len(leftSchema)+ri, rcol.Type, rcol.Source, rcol.Name, rcol.Nullable),
+					),
+					lcol.Name,
+				))
+
+				break
+			}
+		}
+
+		if cond == nil {
+			return nil, ErrNaturalJoinNoCommonColumns.New()
+		}
+
+		for i, col := range leftSchema {
+			if _, ok := shared[strings.ToLower(col.Name)]; ok {
+				continue
+			}
+			projExprs = append(projExprs, expression.NewGetFieldWithTable(i, col.Type, col.Source, col.Name, col.Nullable))
+		}
+
+		for i, col := range rightSchema {
+			if _, ok := shared[strings.ToLower(col.Name)]; ok {
+				continue
+			}
+			projExprs = append(projExprs, expression.NewGetFieldWithTable(len(leftSchema)+i, col.Type, col.Source, col.Name, col.Nullable))
+		}
+
+		innerJoin := plan.NewInnerJoin(join.Left, join.Right, cond)
+
+		// projExprs' indices (li and len(leftSchema)+ri) were already built
+		// relative to innerJoin's row layout, so they don't need fixing up.
+		// fixFieldIndexes matches GetFields by name alone, ignoring Source,
+		// so running it here would collapse a shared column's two operands
+		// (e.g. both sides of Coalesce(left.hash, right.hash)) onto the same
+		// index and silently drop the right-hand reference.
+		return plan.NewProject(projExprs, innerJoin), nil
+	})
+}
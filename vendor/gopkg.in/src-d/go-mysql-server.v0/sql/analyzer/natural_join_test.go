@@ -0,0 +1,88 @@
+package analyzer
+
+import (
+	"testing"
+
+	"gopkg.in/src-d/go-mysql-server.v0/sql"
+	"gopkg.in/src-d/go-mysql-server.v0/sql/expression"
+	"gopkg.in/src-d/go-mysql-server.v0/sql/plan"
+)
+
+// TestResolveNaturalJoinsCoalescesSharedColumn is the scenario from
+// requests/chunk0-3: NATURAL JOIN between two tables sharing a "hash" column
+// must produce exactly one coalesced hash column, plus every other column
+// from both sides.
+func TestResolveNaturalJoinsCoalescesSharedColumn(t *testing.T) {
+	refs := newTestTable("refs", sql.Schema{
+		{Name: "hash", Type: sql.Text},
+		{Name: "name", Type: sql.Text},
+	})
+	commits := newTestTable("commits", sql.Schema{
+		{Name: "hash", Type: sql.Text},
+		{Name: "author_email", Type: sql.Text},
+	})
+
+	join := &plan.NaturalJoin{Left: refs, Right: commits}
+
+	result, err := resolveNaturalJoins(new(Analyzer), join)
+	if err != nil {
+		t.Fatalf("resolveNaturalJoins: %v", err)
+	}
+
+	project, ok := result.(*plan.Project)
+	if !ok {
+		t.Fatalf("expected *plan.Project, got %T", result)
+	}
+
+	if len(project.Expressions) != 3 {
+		t.Fatalf("expected 3 output columns (hash, name, author_email), got %d", len(project.Expressions))
+	}
+
+	alias, ok := project.Expressions[0].(*expression.Alias)
+	if !ok {
+		t.Fatalf("expected the shared column to be coalesced under an alias, got %T", project.Expressions[0])
+	}
+	if alias.Name() != "hash" {
+		t.Fatalf("expected the coalesced column to be named %q, got %q", "hash", alias.Name())
+	}
+
+	coalesce, ok := alias.Child.(*expression.Coalesce)
+	if !ok {
+		t.Fatalf("expected the shared column to be wrapped in a Coalesce, got %T", alias.Child)
+	}
+
+	// This is the bug requests/chunk0-3 called out: a redundant
+	// fixFieldIndexesOnExpressions pass used to rewrite both operands of this
+	// Coalesce to the same (left) index because it matched GetFields by name
+	// alone. Both operands must still point at distinct indices, one on each
+	// side of the join.
+	left, ok := coalesce.Left().(*expression.GetField)
+	if !ok {
+		t.Fatalf("expected Coalesce's left operand to be a GetField, got %T", coalesce.Left())
+	}
+	right, ok := coalesce.Right().(*expression.GetField)
+	if !ok {
+		t.Fatalf("expected Coalesce's right operand to be a GetField, got %T", coalesce.Right())
+	}
+
+	if left.Index() == right.Index() {
+		t.Fatalf("expected Coalesce's two operands to reference distinct indices, both got %d", left.Index())
+	}
+	if left.Index() != 0 {
+		t.Fatalf("expected left operand to reference the left side's hash at index 0, got %d", left.Index())
+	}
+	if right.Index() != len(refs.Schema()) {
+		t.Fatalf("expected right operand to reference the right side's hash at index %d, got %d", len(refs.Schema()), right.Index())
+	}
+}
+
+func TestResolveNaturalJoinsNoCommonColumns(t *testing.T) {
+	left := newTestTable("t1", sql.Schema{{Name: "a", Type: sql.Int32}})
+	right := newTestTable("t2", sql.Schema{{Name: "b", Type: sql.Int32}})
+
+	join := &plan.NaturalJoin{Left: left, Right: right}
+
+	if _, err := resolveNaturalJoins(new(Analyzer), join); err == nil {
+		t.Fatalf("expected an error when the two tables share no columns")
+	}
+}
@@ -0,0 +1,208 @@
+package analyzer
+
+import (
+	"gopkg.in/src-d/go-mysql-server.v0/sql"
+	"gopkg.in/src-d/go-mysql-server.v0/sql/expression"
+	"gopkg.in/src-d/go-mysql-server.v0/sql/plan"
+)
+
+// tableCol identifies a single column of a single table.
+type tableCol struct {
+	table string
+	col   string
+}
+
+// pruneColumns removes columns from subquery aliases and projections that
+// are never referenced by an ancestor node, so intermediate nodes don't
+// carry expressions nobody above them reads. It must run after
+// resolve_columns, so that every GetField is already bound to a real
+// column, and before pushdown, so that the table-level projection pushdown
+// pushdown already does is based on the narrowed column set.
+//
+// It deliberately does not touch sql.Table nodes directly: pushdown already
+// restricts every pushdown-capable table to the exact columns referenced by
+// a GetField anywhere in the tree, and wrapping a table here too would give
+// pushdown's own `case *plan.PushdownProjectionTable: return node, nil`
+// guard a node it treats as already finalized, silently disabling filter
+// pushdown for it.
+func pruneColumns(a *Analyzer, n sql.Node) (sql.Node, error) {
+	a.Log("prune columns, node of type: %T", n)
+	if !n.Resolved() {
+		return n, nil
+	}
+
+	used := make(map[tableCol]struct{})
+	collectUsedColumns(n, used)
+
+	protected := make(map[sql.Node]struct{})
+	protectDistinctChildren(n, protected)
+
+	node, err := n.TransformUp(func(node sql.Node) (sql.Node, error) {
+		if _, ok := protected[node]; ok {
+			// This node sits under a Distinct, whose correctness depends on
+			// every column its child currently exposes, not just the ones
+			// referenced above the Distinct. Leave the whole subtree alone.
+			return node, nil
+		}
+
+		alias, ok := node.(*plan.SubqueryAlias)
+		if !ok {
+			return node, nil
+		}
+
+		child, err := pruneSchemaNode(alias.Child, alias.Name(), used)
+		if err != nil {
+			return nil, err
+		}
+		if child == alias.Child {
+			return node, nil
+		}
+		return plan.NewSubqueryAlias(alias.Name(), child), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return fixFieldIndexesForTree(node)
+}
+
+// protectDistinctChildren records, by node identity, every node in the
+// subtree rooted at a Distinct's child, so pruneColumns can skip rewriting
+// them. This must be computed once up front against the original tree:
+// checking `node.(type) == *plan.Distinct` inside the TransformUp callback
+// itself is too late, since TransformUp transforms children bottom-up and
+// the subtree would already have been pruned by the time the Distinct node
+// is visited.
+func protectDistinctChildren(n sql.Node, protected map[sql.Node]struct{}) {
+	if d, ok := n.(*plan.Distinct); ok {
+		markSubtree(d.Child, protected)
+	}
+
+	for _, child := range n.Children() {
+		protectDistinctChildren(child, protected)
+	}
+}
+
+func markSubtree(n sql.Node, protected map[sql.Node]struct{}) {
+	protected[n] = struct{}{}
+	for _, child := range n.Children() {
+		markSubtree(child, protected)
+	}
+}
+
+// pruneSchemaNode prunes the child of a node that exposes a schema under the
+// given alias (today only used for subquery aliases), keeping a column if it
+// is used under that alias or referenced by name through a GetField with no
+// table, which is how resolve_star expands a wildcard.
+func pruneSchemaNode(child sql.Node, alias string, used map[tableCol]struct{}) (sql.Node, error) {
+	p, ok := child.(*plan.Project)
+	if !ok {
+		return child, nil
+	}
+
+	var exprs []sql.Expression
+	for _, e := range p.Expressions {
+		name := expression.GetName(e)
+		if _, ok := used[tableCol{alias, name}]; ok {
+			exprs = append(exprs, e)
+		} else if _, ok := used[tableCol{"", name}]; ok {
+			exprs = append(exprs, e)
+		}
+	}
+
+	if len(exprs) == 0 || len(exprs) == len(p.Expressions) {
+		return child, nil
+	}
+
+	return plan.NewProject(exprs, p.Child), nil
+}
+
+// collectUsedColumns walks the plan top-down collecting every (table, column)
+// pair referenced by a Project, Filter, Sort, GroupBy or Having node. Columns
+// introduced by resolve_star are already expanded into GetFields by the time
+// this rule runs, so a wildcard never causes its columns to be pruned.
+func collectUsedColumns(n sql.Node, used map[tableCol]struct{}) {
+	switch n := n.(type) {
+	case *plan.Project:
+		collectFromExpressions(n.Expressions, used)
+	case *plan.GroupBy:
+		collectFromExpressions(n.Aggregations, used)
+		collectFromExpressions(n.Grouping, used)
+	default:
+		// n is a sql.Node, not a sql.Expression, so it can't be passed to
+		// expression.Inspect directly. TransformExpressionsUp is the node
+		// method (used the same way by pushdown, above) that hands us each
+		// of the node's own expressions to look at.
+		_, _ = n.TransformExpressionsUp(func(e sql.Expression) (sql.Expression, error) {
+			collectFromExpression(e, used)
+			return e, nil
+		})
+	}
+
+	for _, child := range n.Children() {
+		collectUsedColumns(child, used)
+	}
+}
+
+func collectFromExpressions(exprs []sql.Expression, used map[tableCol]struct{}) {
+	for _, e := range exprs {
+		collectFromExpression(e, used)
+	}
+}
+
+func collectFromExpression(e sql.Expression, used map[tableCol]struct{}) {
+	if e == nil {
+		return
+	}
+	expression.Inspect(e, func(e sql.Expression) bool {
+		if gf, ok := e.(*expression.GetField); ok {
+			used[tableCol{gf.Table(), gf.Name()}] = struct{}{}
+		}
+		return true
+	})
+}
+
+// fixFieldIndexesForTree walks the plan bottom-up renumbering every GetField
+// so its idx matches the (possibly shrunk) schema of its own children,
+// mirroring what fixFieldIndexes already does for a single node during
+// pushdown. A node's own expressions index into the row its children
+// produce, not into its own output schema, so the schema to match against is
+// the concatenation of the node's children's schemas (in row order) rather
+// than node.Schema() itself — using the node's own schema would renumber a
+// GetField against a different, unrelated number space once a sibling
+// column upstream of it has been pruned away.
+func fixFieldIndexesForTree(n sql.Node) (sql.Node, error) {
+	return n.TransformUp(func(node sql.Node) (sql.Node, error) {
+		var schema sql.Schema
+		for _, child := range node.Children() {
+			schema = append(schema, child.Schema()...)
+		}
+		if schema == nil {
+			return node, nil
+		}
+
+		return node.TransformExpressionsUp(func(e sql.Expression) (sql.Expression, error) {
+			gf, ok := e.(*expression.GetField)
+			if !ok {
+				return e, nil
+			}
+
+			for i, col := range schema {
+				if col.Name == gf.Name() && col.Source == gf.Table() {
+					if i == gf.Index() {
+						return e, nil
+					}
+					return expression.NewGetFieldWithTable(
+						i,
+						gf.Type(),
+						gf.Table(),
+						gf.Name(),
+						gf.IsNullable(),
+					), nil
+				}
+			}
+
+			return e, nil
+		})
+	})
+}
@@ -0,0 +1,195 @@
+package analyzer
+
+import (
+	"testing"
+
+	"gopkg.in/src-d/go-mysql-server.v0/sql"
+	"gopkg.in/src-d/go-mysql-server.v0/sql/expression"
+	"gopkg.in/src-d/go-mysql-server.v0/sql/plan"
+)
+
+func TestPruneSchemaNodeDropsUnusedAliasColumns(t *testing.T) {
+	table := newTestTable("commits", sql.Schema{
+		{Name: "hash", Type: sql.Text},
+		{Name: "author_email", Type: sql.Text},
+	})
+
+	project := plan.NewProject([]sql.Expression{
+		expression.NewGetFieldWithTable(0, sql.Text, "commits", "hash", false),
+		expression.NewGetFieldWithTable(1, sql.Text, "commits", "author_email", false),
+	}, table)
+
+	used := map[tableCol]struct{}{
+		{"c", "hash"}: {},
+	}
+
+	pruned, err := pruneSchemaNode(project, "c", used)
+	if err != nil {
+		t.Fatalf("pruneSchemaNode: %v", err)
+	}
+
+	p, ok := pruned.(*plan.Project)
+	if !ok {
+		t.Fatalf("expected *plan.Project, got %T", pruned)
+	}
+
+	if len(p.Expressions) != 1 {
+		t.Fatalf("expected 1 surviving expression, got %d", len(p.Expressions))
+	}
+
+	if name := expression.GetName(p.Expressions[0]); name != "hash" {
+		t.Fatalf("expected surviving column to be hash, got %q", name)
+	}
+}
+
+func TestPruneSchemaNodeKeepsChildWhenEveryColumnIsUsed(t *testing.T) {
+	table := newTestTable("commits", sql.Schema{
+		{Name: "hash", Type: sql.Text},
+	})
+
+	project := plan.NewProject([]sql.Expression{
+		expression.NewGetFieldWithTable(0, sql.Text, "commits", "hash", false),
+	}, table)
+
+	used := map[tableCol]struct{}{
+		{"c", "hash"}: {},
+	}
+
+	pruned, err := pruneSchemaNode(project, "c", used)
+	if err != nil {
+		t.Fatalf("pruneSchemaNode: %v", err)
+	}
+
+	if pruned != project {
+		t.Fatalf("expected the original node back when nothing is pruned")
+	}
+}
+
+func TestProtectDistinctChildrenProtectsTheWholeSubtree(t *testing.T) {
+	table := newTestTable("t", sql.Schema{{Name: "a", Type: sql.Int32}})
+	filter := plan.NewFilter(expression.NewEquals(
+		expression.NewGetFieldWithTable(0, sql.Int32, "t", "a", false),
+		expression.NewLiteral(int32(1), sql.Int32),
+	), table)
+	distinct := &plan.Distinct{Child: filter}
+
+	protected := make(map[sql.Node]struct{})
+	protectDistinctChildren(distinct, protected)
+
+	if _, ok := protected[filter]; !ok {
+		t.Fatalf("expected the Distinct's immediate child to be protected")
+	}
+	if _, ok := protected[table]; !ok {
+		t.Fatalf("expected a node further down the Distinct's subtree to be protected")
+	}
+	if _, ok := protected[distinct]; ok {
+		t.Fatalf("the Distinct node itself is not part of its own protected subtree")
+	}
+}
+
+// TestPruneColumnsLeavesProjectionUnderDistinctIntact is the scenario
+// requests/chunk0-1 called out directly: pruning must not reach inside a
+// Distinct's child just because an ancestor only reads one of its columns,
+// or Distinct ends up computed over fewer columns than the query asked for.
+func TestPruneColumnsLeavesProjectionUnderDistinctIntact(t *testing.T) {
+	table := newTestTable("t", sql.Schema{
+		{Name: "a", Type: sql.Int32},
+		{Name: "b", Type: sql.Int32},
+	})
+
+	inner := plan.NewProject([]sql.Expression{
+		expression.NewGetFieldWithTable(0, sql.Int32, "t", "a", false),
+		expression.NewGetFieldWithTable(1, sql.Int32, "t", "b", false),
+	}, table)
+
+	distinct := &plan.Distinct{Child: inner}
+	sub := plan.NewSubqueryAlias("sub", distinct)
+
+	outer := plan.NewProject([]sql.Expression{
+		expression.NewGetFieldWithTable(0, sql.Int32, "sub", "a", false),
+	}, sub)
+
+	result, err := pruneColumns(new(Analyzer), outer)
+	if err != nil {
+		t.Fatalf("pruneColumns: %v", err)
+	}
+
+	project, ok := result.(*plan.Project)
+	if !ok {
+		t.Fatalf("expected outer node to remain a *plan.Project, got %T", result)
+	}
+
+	alias, ok := project.Child.(*plan.SubqueryAlias)
+	if !ok {
+		t.Fatalf("expected a subquery alias child, got %T", project.Child)
+	}
+
+	d, ok := alias.Child.(*plan.Distinct)
+	if !ok {
+		t.Fatalf("expected a distinct child, got %T", alias.Child)
+	}
+
+	innerProject, ok := d.Child.(*plan.Project)
+	if !ok {
+		t.Fatalf("expected the distinct's child to still be a *plan.Project, got %T", d.Child)
+	}
+
+	if len(innerProject.Expressions) != 2 {
+		t.Fatalf("expected distinct's projection to keep both columns, got %d", len(innerProject.Expressions))
+	}
+}
+
+// TestFixFieldIndexesForTreeKeepsChildIndexAfterLeadingColumnPruned is the
+// regression the request actually asked for: once an earlier sibling column
+// is pruned from a subquery's projection, a surviving GetField must still be
+// renumbered against its own child's schema, not against the pruned
+// project's shrunk output schema.
+func TestFixFieldIndexesForTreeKeepsChildIndexAfterLeadingColumnPruned(t *testing.T) {
+	table := newTestTable("commits", sql.Schema{
+		{Name: "hash", Type: sql.Text},
+		{Name: "author_email", Type: sql.Text},
+		{Name: "committer_email", Type: sql.Text},
+	})
+
+	innerProject := plan.NewProject([]sql.Expression{
+		expression.NewGetFieldWithTable(0, sql.Text, "commits", "hash", false),
+		expression.NewGetFieldWithTable(1, sql.Text, "commits", "author_email", false),
+		expression.NewGetFieldWithTable(2, sql.Text, "commits", "committer_email", false),
+	}, table)
+
+	alias := plan.NewSubqueryAlias("c", innerProject)
+
+	outer := plan.NewProject([]sql.Expression{
+		expression.NewGetFieldWithTable(1, sql.Text, "c", "author_email", false),
+	}, alias)
+
+	result, err := pruneColumns(new(Analyzer), outer)
+	if err != nil {
+		t.Fatalf("pruneColumns: %v", err)
+	}
+
+	outerProject, ok := result.(*plan.Project)
+	if !ok {
+		t.Fatalf("expected *plan.Project, got %T", result)
+	}
+	subAlias, ok := outerProject.Child.(*plan.SubqueryAlias)
+	if !ok {
+		t.Fatalf("expected *plan.SubqueryAlias, got %T", outerProject.Child)
+	}
+	prunedProject, ok := subAlias.Child.(*plan.Project)
+	if !ok {
+		t.Fatalf("expected the subquery's child to still be a *plan.Project, got %T", subAlias.Child)
+	}
+	if len(prunedProject.Expressions) != 1 {
+		t.Fatalf("expected only author_email to survive pruning, got %d expressions", len(prunedProject.Expressions))
+	}
+
+	gf, ok := prunedProject.Expressions[0].(*expression.GetField)
+	if !ok {
+		t.Fatalf("expected a *expression.GetField, got %T", prunedProject.Expressions[0])
+	}
+
+	if gf.Index() != 1 {
+		t.Fatalf("expected the surviving GetField to keep index 1 (author_email's position in commits), got %d", gf.Index())
+	}
+}
@@ -0,0 +1,282 @@
+package analyzer
+
+import (
+	"strings"
+
+	"gopkg.in/src-d/go-mysql-server.v0/sql"
+	"gopkg.in/src-d/go-mysql-server.v0/sql/expression"
+	"gopkg.in/src-d/go-mysql-server.v0/sql/plan"
+)
+
+// colScope is the set of tables visible at a point in the plan, used to turn
+// an UnresolvedColumn into a qualified one.
+type colScope struct {
+	tables       map[string]sql.Node
+	tableAliases map[string]string
+	colIndex     map[string][]string
+	structOwners map[string][]string
+}
+
+func newColScope() *colScope {
+	return &colScope{
+		tables:       make(map[string]sql.Node),
+		tableAliases: make(map[string]string),
+		colIndex:     make(map[string][]string),
+		structOwners: make(map[string][]string),
+	}
+}
+
+// index registers a table (or table alias) and the columns of its schema.
+func (s *colScope) index(table string, schema sql.Schema) {
+	for _, col := range schema {
+		s.colIndex[col.Name] = append(s.colIndex[col.Name], table)
+	}
+	structColumnOwners(schema, table, s.structOwners)
+}
+
+// merge folds other into s, used to combine the scopes of a node's children
+// (e.g. both sides of a join) into the scope visible to their parent.
+func (s *colScope) merge(other *colScope) {
+	for k, v := range other.tables {
+		s.tables[k] = v
+	}
+	for k, v := range other.tableAliases {
+		s.tableAliases[k] = v
+	}
+	for k, v := range other.colIndex {
+		s.colIndex[k] = append(s.colIndex[k], v...)
+	}
+	for k, v := range other.structOwners {
+		s.structOwners[k] = append(s.structOwners[k], v...)
+	}
+}
+
+// qualify turns an UnresolvedColumn into one qualified with a table name,
+// disambiguating unqualified references and resolving struct-column access,
+// exactly as the single-scope implementation used to.
+func (s *colScope) qualify(col *expression.UnresolvedColumn) (sql.Expression, error) {
+	col = expression.NewUnresolvedQualifiedColumn(col.Table(), col.Name())
+
+	if col.Table() == "" {
+		tables := dedupStrings(s.colIndex[col.Name()])
+		switch len(tables) {
+		case 0:
+			return nil, ErrColumnTableNotFound.New(col.Table(), col.Name())
+		case 1:
+			return expression.NewUnresolvedQualifiedColumn(tables[0], col.Name()), nil
+		default:
+			return nil, ErrAmbiguousColumnName.New(col.Name(), strings.Join(tables, ", "))
+		}
+	}
+
+	if real, ok := s.tableAliases[col.Table()]; ok {
+		col = expression.NewUnresolvedQualifiedColumn(real, col.Name())
+	}
+
+	if _, ok := s.tables[col.Table()]; ok {
+		return col, nil
+	}
+
+	// The "table" component might actually be a struct column, as in `s.i`
+	// where `s` is a struct column of some real table. Rewrite it so
+	// resolve_columns can build a nested GetStructField instead of failing
+	// to find a table named `s`.
+	owners := dedupStrings(s.structOwners[col.Table()])
+	switch len(owners) {
+	case 0:
+		return nil, sql.ErrTableNotFound.New(col.Table())
+	case 1:
+		return expression.NewUnresolvedQualifiedColumn(owners[0], col.Table()+"."+col.Name()), nil
+	default:
+		return nil, ErrAmbiguousColumnName.New(col.Table(), strings.Join(owners, ", "))
+	}
+}
+
+func (s *colScope) qualifyExpressions(exprs []sql.Expression) ([]sql.Expression, error) {
+	result := make([]sql.Expression, len(exprs))
+	for i, e := range exprs {
+		qualified, err := e.TransformUp(func(e sql.Expression) (sql.Expression, error) {
+			col, ok := e.(*expression.UnresolvedColumn)
+			if !ok {
+				return e, nil
+			}
+			return s.qualify(col)
+		})
+		if err != nil {
+			return nil, err
+		}
+		result[i] = qualified
+	}
+	return result, nil
+}
+
+// qualifyColumnsInScope walks the plan qualifying every UnresolvedColumn it
+// finds, returning the transformed node together with two scopes: in is the
+// scope of the columns below this node (the tables it reads from, before any
+// aliasing this node itself performs) and out is the scope this node exposes
+// to its parent. A Sort, Having or GroupBy directly above a Project
+// qualifies its own expressions against the Project's in: a Project's own
+// output schema may rename columns (`i AS foo`), but such clauses refer to
+// the columns of its *input*. A scope only records tables and their columns,
+// and the aliases a Project produces add neither, so every node, a Project
+// included, returns the same scope as in and out; an alias name such as
+// `foo` is in neither scope.
+func qualifyColumnsInScope(a *Analyzer, n sql.Node) (node sql.Node, in *colScope, out *colScope, err error) {
+	a.Log("qualifying columns, node of type: %T", n)
+	switch n := n.(type) {
+	case *plan.TableAlias:
+		switch t := n.Child.(type) {
+		case sql.Table:
+			scope := newColScope()
+			scope.tableAliases[n.Name()] = t.Name()
+			scope.tables[t.Name()] = t
+			scope.index(t.Name(), t.Schema())
+			return n, scope, scope, nil
+		default:
+			child, _, childOut, err := qualifyColumnsInScope(a, n.Child)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			scope := newColScope()
+			scope.merge(childOut)
+			scope.tables[n.Name()] = child
+			scope.index(n.Name(), n.Schema())
+			return plan.NewTableAlias(n.Name(), child), scope, scope, nil
+		}
+	case sql.Table:
+		scope := newColScope()
+		scope.tables[n.Name()] = n
+		scope.index(n.Name(), n.Schema())
+		return n, scope, scope, nil
+	case *plan.Project:
+		child, _, childOut, err := qualifyColumnsInScope(a, n.Child)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		exprs, err := childOut.qualifyExpressions(n.Expressions)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		// The Project's output scope, as seen by anything above a Sort,
+		// Having or GroupBy that already consulted the input scope, is
+		// simply the input scope again: aliases do not introduce new
+		// tables or struct columns of their own.
+		return plan.NewProject(exprs, child), childOut, childOut, nil
+	case *plan.Sort:
+		child, in, out, err := qualifyColumnsInScope(a, n.Child)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		scope := out
+		if _, ok := n.Child.(*plan.Project); ok {
+			scope = in
+		}
+
+		exprs, err := scope.qualifyExpressions(sortFieldsToExpressions(n.SortFields))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		fields := expressionsToSortFields(n.SortFields)(exprs)
+		return plan.NewSort(fields, child), out, out, nil
+	case *plan.Having:
+		child, in, out, err := qualifyColumnsInScope(a, n.Child)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		scope := out
+		if _, ok := n.Child.(*plan.Project); ok {
+			scope = in
+		}
+
+		cond, err := scope.qualifyExpressions([]sql.Expression{n.Cond})
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		return plan.NewHaving(cond[0], child), out, out, nil
+	case *plan.GroupBy:
+		child, in, out, err := qualifyColumnsInScope(a, n.Child)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		scope := out
+		if _, ok := n.Child.(*plan.Project); ok {
+			scope = in
+		}
+
+		grouping, err := scope.qualifyExpressions(n.Grouping)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		// The select list of a GROUP BY is qualified against the output
+		// scope like a normal Project would be, since it can introduce its
+		// own aliases that Having above it may reference.
+		aggregations, err := out.qualifyExpressions(n.Aggregations)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		return plan.NewGroupBy(aggregations, grouping, child), out, out, nil
+	default:
+		scope := newColScope()
+		var children []sql.Node
+		changed := false
+		for _, child := range n.Children() {
+			newChild, _, childOut, err := qualifyColumnsInScope(a, child)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			if newChild != child {
+				changed = true
+			}
+			children = append(children, newChild)
+			scope.merge(childOut)
+		}
+
+		node := n
+		if changed {
+			node, err = n.WithChildren(children...)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+		}
+
+		qualified, err := node.TransformExpressionsUp(func(e sql.Expression) (sql.Expression, error) {
+			col, ok := e.(*expression.UnresolvedColumn)
+			if !ok {
+				return e, nil
+			}
+			return scope.qualify(col)
+		})
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		return qualified, scope, scope, nil
+	}
+}
+
+func sortFieldsToExpressions(fields []sql.SortField) []sql.Expression {
+	exprs := make([]sql.Expression, len(fields))
+	for i, f := range fields {
+		exprs[i] = f.Column
+	}
+	return exprs
+}
+
+func expressionsToSortFields(orig []sql.SortField) func([]sql.Expression) []sql.SortField {
+	return func(exprs []sql.Expression) []sql.SortField {
+		fields := make([]sql.SortField, len(exprs))
+		for i, e := range exprs {
+			fields[i] = sql.SortField{Column: e, Order: orig[i].Order}
+		}
+		return fields
+	}
+}
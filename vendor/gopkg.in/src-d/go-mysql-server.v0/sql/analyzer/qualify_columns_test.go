@@ -0,0 +1,151 @@
+package analyzer
+
+import (
+	"testing"
+
+	"gopkg.in/src-d/go-mysql-server.v0/sql"
+	"gopkg.in/src-d/go-mysql-server.v0/sql/expression"
+	"gopkg.in/src-d/go-mysql-server.v0/sql/plan"
+)
+
+// TestQualifyColumnsOrderByQualifiesAgainstProjectInput is the first scenario
+// from requests/chunk0-7: `SELECT i AS foo FROM t ORDER BY t.i` must resolve
+// `t.i` against t's own schema, not against the Project's output alias
+// "foo".
+func TestQualifyColumnsOrderByQualifiesAgainstProjectInput(t *testing.T) {
+	table := newTestTable("t", sql.Schema{{Name: "i", Type: sql.Int32}})
+
+	project := plan.NewProject([]sql.Expression{
+		expression.NewAlias(expression.NewUnresolvedColumn("i"), "foo"),
+	}, table)
+
+	sort := plan.NewSort([]sql.SortField{
+		{Column: expression.NewUnresolvedQualifiedColumn("t", "i")},
+	}, project)
+
+	node, _, _, err := qualifyColumnsInScope(new(Analyzer), sort)
+	if err != nil {
+		t.Fatalf("qualifyColumnsInScope: %v", err)
+	}
+
+	qualifiedSort, ok := node.(*plan.Sort)
+	if !ok {
+		t.Fatalf("expected *plan.Sort, got %T", node)
+	}
+
+	col, ok := qualifiedSort.SortFields[0].Column.(*expression.UnresolvedColumn)
+	if !ok {
+		t.Fatalf("expected the sort field to still be an UnresolvedColumn pending resolve_columns, got %T", qualifiedSort.SortFields[0].Column)
+	}
+	if col.Table() != "t" || col.Name() != "i" {
+		t.Fatalf("expected t.i, got %s.%s", col.Table(), col.Name())
+	}
+}
+
+// TestQualifyColumnsOrderByOnComputedAlias covers `SELECT i+1 AS x FROM t
+// ORDER BY t.i`: the projection's own alias is computed, not a plain column,
+// which must not prevent ORDER BY from reaching back to the input scope.
+func TestQualifyColumnsOrderByOnComputedAlias(t *testing.T) {
+	table := newTestTable("t", sql.Schema{{Name: "i", Type: sql.Int32}})
+
+	project := plan.NewProject([]sql.Expression{
+		expression.NewAlias(
+			expression.NewPlus(expression.NewUnresolvedColumn("i"), expression.NewLiteral(int32(1), sql.Int32)),
+			"x",
+		),
+	}, table)
+
+	sort := plan.NewSort([]sql.SortField{
+		{Column: expression.NewUnresolvedQualifiedColumn("t", "i")},
+	}, project)
+
+	node, _, _, err := qualifyColumnsInScope(new(Analyzer), sort)
+	if err != nil {
+		t.Fatalf("qualifyColumnsInScope: %v", err)
+	}
+
+	qualifiedSort, ok := node.(*plan.Sort)
+	if !ok {
+		t.Fatalf("expected *plan.Sort, got %T", node)
+	}
+
+	col, ok := qualifiedSort.SortFields[0].Column.(*expression.UnresolvedColumn)
+	if !ok {
+		t.Fatalf("expected an UnresolvedColumn, got %T", qualifiedSort.SortFields[0].Column)
+	}
+	if col.Table() != "t" || col.Name() != "i" {
+		t.Fatalf("expected t.i, got %s.%s", col.Table(), col.Name())
+	}
+}
+
+// TestQualifyColumnsGroupByHavingQualifiesAgainstInput covers `SELECT i AS
+// foo FROM t GROUP BY t.i HAVING t.i > 1`.
+func TestQualifyColumnsGroupByHavingQualifiesAgainstInput(t *testing.T) {
+	table := newTestTable("t", sql.Schema{{Name: "i", Type: sql.Int32}})
+
+	group := plan.NewGroupBy(
+		[]sql.Expression{expression.NewAlias(expression.NewUnresolvedColumn("i"), "foo")},
+		[]sql.Expression{expression.NewUnresolvedQualifiedColumn("t", "i")},
+		table,
+	)
+
+	having := plan.NewHaving(
+		expression.NewGreaterThan(
+			expression.NewUnresolvedQualifiedColumn("t", "i"),
+			expression.NewLiteral(int32(1), sql.Int32),
+		),
+		group,
+	)
+
+	node, _, _, err := qualifyColumnsInScope(new(Analyzer), having)
+	if err != nil {
+		t.Fatalf("qualifyColumnsInScope: %v", err)
+	}
+
+	qualifiedHaving, ok := node.(*plan.Having)
+	if !ok {
+		t.Fatalf("expected *plan.Having, got %T", node)
+	}
+
+	gt, ok := qualifiedHaving.Cond.(*expression.GreaterThan)
+	if !ok {
+		t.Fatalf("expected *expression.GreaterThan, got %T", qualifiedHaving.Cond)
+	}
+
+	col, ok := gt.Left().(*expression.UnresolvedColumn)
+	if !ok {
+		t.Fatalf("expected an UnresolvedColumn, got %T", gt.Left())
+	}
+	if col.Table() != "t" || col.Name() != "i" {
+		t.Fatalf("expected t.i, got %s.%s", col.Table(), col.Name())
+	}
+}
+
+func TestColScopeQualifyAmbiguousColumn(t *testing.T) {
+	scope := newColScope()
+	scope.index("t1", sql.Schema{{Name: "i", Type: sql.Int32, Source: "t1"}})
+	scope.index("t2", sql.Schema{{Name: "i", Type: sql.Int32, Source: "t2"}})
+
+	_, err := scope.qualify(expression.NewUnresolvedColumn("i"))
+	if err == nil {
+		t.Fatalf("expected an ambiguous column error when two tables share a column name")
+	}
+}
+
+func TestColScopeQualifyUnqualifiedResolvesUniqueTable(t *testing.T) {
+	scope := newColScope()
+	scope.index("t", sql.Schema{{Name: "i", Type: sql.Int32, Source: "t"}})
+
+	col, err := scope.qualify(expression.NewUnresolvedColumn("i"))
+	if err != nil {
+		t.Fatalf("qualify: %v", err)
+	}
+
+	uc, ok := col.(*expression.UnresolvedColumn)
+	if !ok {
+		t.Fatalf("expected *expression.UnresolvedColumn, got %T", col)
+	}
+	if uc.Table() != "t" {
+		t.Fatalf("expected the column to resolve to table %q, got %q", "t", uc.Table())
+	}
+}
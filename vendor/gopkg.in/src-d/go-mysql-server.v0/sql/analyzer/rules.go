@@ -1,9 +1,6 @@
 package analyzer
 
 import (
-	"strings"
-
-	errors "gopkg.in/src-d/go-errors.v1"
 	"gopkg.in/src-d/go-mysql-server.v0/sql"
 	"gopkg.in/src-d/go-mysql-server.v0/sql/expression"
 	"gopkg.in/src-d/go-mysql-server.v0/sql/plan"
@@ -15,22 +12,29 @@ var DefaultRules = []Rule{
 	{"resolve_tables", resolveTables},
 	{"qualify_columns", qualifyColumns},
 	{"resolve_columns", resolveColumns},
+	{"resolve_natural_joins", resolveNaturalJoins},
+	{"unnest_in_subqueries", unnestInSubqueries},
+	{"validate_group_by", validateGroupBy},
 	{"resolve_database", resolveDatabase},
 	{"resolve_star", resolveStar},
 	{"resolve_functions", resolveFunctions},
+	{"prune_columns", pruneColumns},
 	{"pushdown", pushdown},
 	{"optimize_distinct", optimizeDistinct},
 }
 
 var (
 	// ErrColumnTableNotFound is returned when the column does not exist in a
-	// the table.
-	ErrColumnTableNotFound = errors.NewKind("table %q does not have column %q")
+	// the table. Maps to MySQL error 1054 (ER_BAD_FIELD_ERROR), SQLSTATE
+	// 42S22.
+	ErrColumnTableNotFound = newCodedKind("table %q does not have column %q", 1054, "42S22")
 	// ErrAmbiguousColumnName is returned when there is a column reference that
-	// is present in more than one table.
-	ErrAmbiguousColumnName = errors.NewKind("ambiguous column name %q, it's present in all these tables: %v")
-	// ErrFieldMissing is returned when the field is not on the schema.
-	ErrFieldMissing = errors.NewKind("field %q is not on schema")
+	// is present in more than one table. Maps to MySQL error 1052
+	// (ER_NON_UNIQ_ERROR), SQLSTATE 23000.
+	ErrAmbiguousColumnName = newCodedKind("ambiguous column name %q, it's present in all these tables: %v", 1052, "23000")
+	// ErrFieldMissing is returned when the field is not on the schema. Maps
+	// to MySQL error 1054 (ER_BAD_FIELD_ERROR), SQLSTATE 42S22.
+	ErrFieldMissing = newCodedKind("field %q is not on schema", 1054, "42S22")
 )
 
 func resolveSubqueries(a *Analyzer, n sql.Node) (sql.Node, error) {
@@ -52,71 +56,8 @@ func resolveSubqueries(a *Analyzer, n sql.Node) (sql.Node, error) {
 
 func qualifyColumns(a *Analyzer, n sql.Node) (sql.Node, error) {
 	a.Log("qualify columns")
-	tables := make(map[string]sql.Node)
-	tableAliases := make(map[string]string)
-	colIndex := make(map[string][]string)
-
-	indexCols := func(table string, schema sql.Schema) {
-		for _, col := range schema {
-			colIndex[col.Name] = append(colIndex[col.Name], table)
-		}
-	}
-
-	return n.TransformUp(func(n sql.Node) (sql.Node, error) {
-		a.Log("transforming node of type: %T", n)
-		switch n := n.(type) {
-		case *plan.TableAlias:
-			switch t := n.Child.(type) {
-			case sql.Table:
-				tableAliases[n.Name()] = t.Name()
-			default:
-				tables[n.Name()] = n.Child
-				indexCols(n.Name(), n.Schema())
-			}
-		case sql.Table:
-			tables[n.Name()] = n
-			indexCols(n.Name(), n.Schema())
-		}
-
-		return n.TransformExpressionsUp(func(e sql.Expression) (sql.Expression, error) {
-			a.Log("transforming expression of type: %T", e)
-			col, ok := e.(*expression.UnresolvedColumn)
-			if !ok {
-				return e, nil
-			}
-
-			col = expression.NewUnresolvedQualifiedColumn(col.Table(), col.Name())
-
-			if col.Table() == "" {
-				tables := dedupStrings(colIndex[col.Name()])
-				switch len(tables) {
-				case 0:
-					return nil, ErrColumnTableNotFound.New(col.Table(), col.Name())
-				case 1:
-					col = expression.NewUnresolvedQualifiedColumn(
-						tables[0],
-						col.Name(),
-					)
-				default:
-					return nil, ErrAmbiguousColumnName.New(col.Name(), strings.Join(tables, ", "))
-				}
-			} else {
-				if real, ok := tableAliases[col.Table()]; ok {
-					col = expression.NewUnresolvedQualifiedColumn(
-						real,
-						col.Name(),
-					)
-				}
-
-				if _, ok := tables[col.Table()]; !ok {
-					return nil, sql.ErrTableNotFound.New(col.Table())
-				}
-			}
-
-			a.Log("column %q was qualified with table %q", col.Name(), col.Table())
-			return col, nil
-		})
-	})
+	node, _, _, err := qualifyColumnsInScope(a, n)
+	return node, err
 }
 
 func resolveDatabase(a *Analyzer, n sql.Node) (sql.Node, error) {
@@ -238,6 +179,10 @@ func resolveColumns(a *Analyzer, n sql.Node) (sql.Node, error) {
 				return e, nil
 			}
 
+			if structCol, field, ok := splitStructAccess(uc.Name()); ok {
+				return resolveStructField(colMap, uc.Table(), structCol, field)
+			}
+
 			columnsInfo, ok := colMap[uc.Name()]
 			if !ok {
 				return nil, ErrColumnTableNotFound.New(uc.Table(), uc.Name())
@@ -310,16 +255,19 @@ func resolveFunctions(a *Analyzer, n sql.Node) (sql.Node, error) {
 func optimizeDistinct(a *Analyzer, node sql.Node) (sql.Node, error) {
 	a.Log("optimize distinct, node of type: %T", node)
 	if node, ok := node.(*plan.Distinct); ok {
-		var isSorted bool
-		_, _ = node.TransformUp(func(node sql.Node) (sql.Node, error) {
-			a.Log("checking for optimization in node of type: %T", node)
-			if _, ok := node.(*plan.Sort); ok {
-				isSorted = true
-			}
-			return node, nil
-		})
+		fd := computeFuncDeps(node.Child)
+
+		var distinctCols []tableCol
+		for _, col := range node.Child.Schema() {
+			distinctCols = append(distinctCols, tableCol{col.Source, col.Name})
+		}
+
+		if fd.HasKeySubsetOf(distinctCols) {
+			a.Log("distinct removed, child output is already unique")
+			return node.Child, nil
+		}
 
-		if isSorted {
+		if len(fd.SortPrefix) > 0 && isSupersetOf(fd.SortPrefix, distinctCols) {
 			a.Log("distinct optimized for ordered output")
 			return plan.NewOrderedDistinct(node.Child), nil
 		}
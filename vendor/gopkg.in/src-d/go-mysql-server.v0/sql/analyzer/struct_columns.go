@@ -0,0 +1,75 @@
+package analyzer
+
+import (
+	"strings"
+
+	"gopkg.in/src-d/go-mysql-server.v0/sql"
+	"gopkg.in/src-d/go-mysql-server.v0/sql/expression"
+)
+
+// splitStructAccess splits a column name such as "s.i" into its struct
+// column name and field name. The second return value is false if name does
+// not look like a nested field access.
+func splitStructAccess(name string) (structCol, field string, ok bool) {
+	idx := strings.Index(name, ".")
+	if idx < 0 {
+		return "", "", false
+	}
+	return name[:idx], name[idx+1:], true
+}
+
+// resolveStructField builds a GetStructField expression for a nested access
+// such as `s.i`, where structCol is the name of a struct-typed column found
+// in colMap and field is the name of one of its schema fields.
+func resolveStructField(colMap map[string][]columnInfo, table, structCol, field string) (sql.Expression, error) {
+	infos, ok := colMap[structCol]
+	if !ok {
+		return nil, ErrFieldMissing.New(structCol + "." + field)
+	}
+
+	var ci columnInfo
+	var found bool
+	for _, c := range infos {
+		if c.col.Source == table {
+			ci = c
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, ErrFieldMissing.New(structCol + "." + field)
+	}
+
+	st, ok := ci.col.Type.(sql.Struct)
+	if !ok {
+		return nil, ErrFieldMissing.New(structCol + "." + field)
+	}
+
+	fieldIdx := st.FieldIndex(field)
+	if fieldIdx < 0 {
+		return nil, ErrFieldMissing.New(structCol + "." + field)
+	}
+
+	parent := expression.NewGetFieldWithTable(
+		ci.idx,
+		ci.col.Type,
+		ci.col.Source,
+		ci.col.Name,
+		ci.col.Nullable,
+	)
+
+	fieldType := st.Schema()[fieldIdx].Type
+
+	return expression.NewGetStructField(parent, field, fieldIdx, fieldType), nil
+}
+
+// structColumnOwners maps the name of a struct-typed column to the tables
+// that declare it, so qualifyColumns can tell `s.i` (struct column `s`,
+// field `i`) apart from `t.i` (table `t`, column `i`).
+func structColumnOwners(schema sql.Schema, table string, owners map[string][]string) {
+	for _, col := range schema {
+		if _, ok := col.Type.(sql.Struct); ok {
+			owners[col.Name] = append(owners[col.Name], table)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package analyzer
+
+import (
+	"testing"
+
+	"gopkg.in/src-d/go-mysql-server.v0/sql"
+	"gopkg.in/src-d/go-mysql-server.v0/sql/expression"
+	"gopkg.in/src-d/go-mysql-server.v0/sql/plan"
+)
+
+func TestSplitStructAccess(t *testing.T) {
+	cases := []struct {
+		name       string
+		structCol  string
+		field      string
+		ok         bool
+	}{
+		{"s.i", "s", "i", true},
+		{"i", "", "", false},
+		{"t.s.t", "t", "s.t", true},
+	}
+
+	for _, c := range cases {
+		structCol, field, ok := splitStructAccess(c.name)
+		if ok != c.ok || structCol != c.structCol || field != c.field {
+			t.Errorf("splitStructAccess(%q) = (%q, %q, %v), want (%q, %q, %v)",
+				c.name, structCol, field, ok, c.structCol, c.field, c.ok)
+		}
+	}
+}
+
+func TestResolveStructField(t *testing.T) {
+	structType := sql.StructType(sql.Schema{
+		{Name: "i", Type: sql.Int32},
+	})
+
+	schema := sql.Schema{{Name: "s", Type: structType, Source: "t"}}
+	colMap := map[string][]columnInfo{
+		"s": {{idx: 0, col: schema[0]}},
+	}
+
+	e, err := resolveStructField(colMap, "t", "s", "i")
+	if err != nil {
+		t.Fatalf("resolveStructField: %v", err)
+	}
+
+	gsf, ok := e.(*expression.GetStructField)
+	if !ok {
+		t.Fatalf("expected *expression.GetStructField, got %T", e)
+	}
+
+	if gsf.FieldName() != "i" {
+		t.Fatalf("expected field name %q, got %q", "i", gsf.FieldName())
+	}
+	if gsf.Type() != sql.Int32 {
+		t.Fatalf("expected field type %v, got %v", sql.Int32, gsf.Type())
+	}
+
+	parent, ok := gsf.Child.(*expression.GetField)
+	if !ok {
+		t.Fatalf("expected the struct field's parent to be *expression.GetField, got %T", gsf.Child)
+	}
+	if parent.Index() != 0 || parent.Table() != "t" || parent.Name() != "s" {
+		t.Fatalf("expected the parent GetField to address t.s at index 0, got %s.%s at index %d",
+			parent.Table(), parent.Name(), parent.Index())
+	}
+}
+
+// TestResolveColumnsResolvesStructFieldAccessEndToEnd is the integration test
+// requests/chunk0-2 originally asked for: resolveColumns, driven on a plan
+// referencing `t.s.i` (an UnresolvedColumn qualified to table "t" with the
+// struct-access name "s.i"), must end up with a *expression.GetStructField
+// over a GetField for the struct column itself, not just the unit-level
+// resolveStructField call above.
+func TestResolveColumnsResolvesStructFieldAccessEndToEnd(t *testing.T) {
+	structType := sql.StructType(sql.Schema{
+		{Name: "i", Type: sql.Int32},
+	})
+
+	table := newTestTable("t", sql.Schema{{Name: "s", Type: structType}})
+
+	project := plan.NewProject(
+		[]sql.Expression{expression.NewUnresolvedQualifiedColumn("t", "s.i")},
+		table,
+	)
+
+	result, err := resolveColumns(new(Analyzer), project)
+	if err != nil {
+		t.Fatalf("resolveColumns: %v", err)
+	}
+
+	p, ok := result.(*plan.Project)
+	if !ok {
+		t.Fatalf("expected *plan.Project, got %T", result)
+	}
+
+	gsf, ok := p.Expressions[0].(*expression.GetStructField)
+	if !ok {
+		t.Fatalf("expected t.s.i to resolve to *expression.GetStructField, got %T", p.Expressions[0])
+	}
+	if gsf.FieldName() != "i" {
+		t.Fatalf("expected field name %q, got %q", "i", gsf.FieldName())
+	}
+
+	parent, ok := gsf.Child.(*expression.GetField)
+	if !ok {
+		t.Fatalf("expected the struct field's parent to be *expression.GetField, got %T", gsf.Child)
+	}
+	if parent.Index() != 0 || parent.Table() != "t" || parent.Name() != "s" {
+		t.Fatalf("expected the parent GetField to address t.s at index 0, got %s.%s at index %d",
+			parent.Table(), parent.Name(), parent.Index())
+	}
+}
+
+func TestResolveStructFieldUnknownColumn(t *testing.T) {
+	colMap := map[string][]columnInfo{}
+
+	if _, err := resolveStructField(colMap, "t", "s", "i"); err == nil {
+		t.Fatalf("expected an error for an unknown struct column")
+	}
+}
+
+func TestStructColumnOwners(t *testing.T) {
+	structType := sql.StructType(sql.Schema{
+		{Name: "i", Type: sql.Int32},
+	})
+
+	schema := sql.Schema{
+		{Name: "s", Type: structType},
+		{Name: "a", Type: sql.Int32},
+	}
+
+	owners := make(map[string][]string)
+	structColumnOwners(schema, "t", owners)
+
+	if got := owners["s"]; len(got) != 1 || got[0] != "t" {
+		t.Fatalf("expected struct column %q to be owned by %q, got %v", "s", "t", got)
+	}
+	if _, ok := owners["a"]; ok {
+		t.Fatalf("did not expect a non-struct column to be recorded as a struct owner")
+	}
+}
@@ -0,0 +1,72 @@
+package expression
+
+import (
+	"fmt"
+
+	"gopkg.in/src-d/go-mysql-server.v0/sql"
+)
+
+// GetStructField is an expression that accesses a named field of a
+// Struct-typed column, e.g. the `i` in `s.i` where `s` is a struct column.
+type GetStructField struct {
+	UnaryExpression
+	fieldName string
+	fieldIdx  int
+	fieldType sql.Type
+}
+
+// NewGetStructField creates a GetStructField that extracts the field
+// fieldName from the value produced by parent, which must evaluate to a
+// struct value: a sql.Row holding the fields in the order of the
+// sql.Struct's schema. fieldIdx is the field's position in that schema, and
+// fieldType its resolved type, so no further lookup is needed at eval time.
+func NewGetStructField(parent sql.Expression, fieldName string, fieldIdx int, fieldType sql.Type) *GetStructField {
+	return &GetStructField{
+		UnaryExpression: UnaryExpression{Child: parent},
+		fieldName:       fieldName,
+		fieldIdx:        fieldIdx,
+		fieldType:       fieldType,
+	}
+}
+
+// FieldName returns the name of the accessed field.
+func (e *GetStructField) FieldName() string { return e.fieldName }
+
+// Type implements the Expression interface.
+func (e *GetStructField) Type() sql.Type { return e.fieldType }
+
+// Eval implements the Expression interface.
+func (e *GetStructField) Eval(ctx *sql.Context, row sql.Row) (interface{}, error) {
+	v, err := e.Child.Eval(ctx, row)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, nil
+	}
+
+	fields, ok := v.(sql.Row)
+	if !ok {
+		return nil, fmt.Errorf("expecting struct value, got %T", v)
+	}
+
+	if e.fieldIdx < 0 || e.fieldIdx >= len(fields) {
+		return nil, fmt.Errorf("field %q is out of range for struct", e.fieldName)
+	}
+
+	return fields[e.fieldIdx], nil
+}
+
+// TransformUp implements the Expression interface.
+func (e *GetStructField) TransformUp(f func(sql.Expression) (sql.Expression, error)) (sql.Expression, error) {
+	child, err := e.Child.TransformUp(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return f(NewGetStructField(child, e.fieldName, e.fieldIdx, e.fieldType))
+}
+
+func (e *GetStructField) String() string {
+	return fmt.Sprintf("%s.%s", e.Child, e.fieldName)
+}
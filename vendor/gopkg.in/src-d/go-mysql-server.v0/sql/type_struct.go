@@ -0,0 +1,41 @@
+package sql
+
+// Struct is the type of a column whose values are themselves composed of
+// named, typed fields, e.g. a commit's author (name, email, when) or a tree
+// entry's mode and name. It lets gitbase expose such values as a single
+// schema column instead of flattening them into the parent schema.
+type Struct struct {
+	schema Schema
+}
+
+// StructType creates a new Struct type from the given schema, which
+// describes the fields the struct value is made of.
+func StructType(schema Schema) Struct {
+	return Struct{schema: schema}
+}
+
+// Schema returns the schema of the fields of the struct.
+func (t Struct) Schema() Schema {
+	return t.schema
+}
+
+// Name implements the Type interface.
+func (t Struct) Name() string {
+	return "struct"
+}
+
+// Type implements the Type interface.
+func (t Struct) Type() Type {
+	return t
+}
+
+// FieldIndex returns the index of the field with the given name inside the
+// struct's schema, or -1 if there is no such field.
+func (t Struct) FieldIndex(name string) int {
+	for i, col := range t.schema {
+		if col.Name == name {
+			return i
+		}
+	}
+	return -1
+}